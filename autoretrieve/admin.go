@@ -0,0 +1,149 @@
+package autoretrieve
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/application-research/estuary/util"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multihash"
+	"gorm.io/gorm"
+)
+
+// AnnounceLatest re-publishes the most recent advertisement for handle
+// out-of-band from the ticker loop in Run. It's useful when an indexer
+// missed a pubsub message, or when operators onboard a new indexer and need
+// to nudge it to resync.
+func (arEng *AutoretrieveEngine) AnnounceLatest(ctx context.Context, handle string) (cid.Cid, error) {
+	var ar Autoretrieve
+	if err := arEng.db.Find(&ar, "handle = ?", handle).Error; err != nil {
+		return cid.Undef, err
+	}
+	if ar.LastAdCid == "" {
+		return cid.Undef, fmt.Errorf("no advertisement has been published for %s yet", handle)
+	}
+
+	adCid, err := cid.Decode(ar.LastAdCid)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("could not decode last ad cid for %s: %w", handle, err)
+	}
+
+	providerID, retrievalAddresses, err := providerInfoForHandle(ar)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	if arEng.publisherKind != HTTPPublisher {
+		// Re-publish through the embedded engine's own publish path (the same
+		// one NotifyPut uses), instead of writing raw CID bytes to the topic
+		// ourselves -- indexers expect the dagsync/legs wire format the
+		// engine produces, not a bare CID.
+		if _, err := arEng.Engine.PublishLatest(ctx); err != nil {
+			return cid.Undef, fmt.Errorf("could not publish over gossipsub: %w", err)
+		}
+	}
+	if arEng.publisherKind != DataTransferPublisher {
+		if err := arEng.httpSender.Send(ctx, adCid.String(), providerID, retrievalAddresses); err != nil {
+			return cid.Undef, fmt.Errorf("could not send HTTP announcement: %w", err)
+		}
+	}
+
+	return adCid, nil
+}
+
+// AnnounceLatestHTTP sends the latest-ad announcement for handle to ad-hoc
+// HTTP indexer URLs, without adding them to the engine's configured senders.
+// This is useful for debugging whether a new indexer is reachable and
+// correctly ingesting before registering it permanently.
+func (arEng *AutoretrieveEngine) AnnounceLatestHTTP(ctx context.Context, handle string, urls []string) (cid.Cid, error) {
+	var ar Autoretrieve
+	if err := arEng.db.Find(&ar, "handle = ?", handle).Error; err != nil {
+		return cid.Undef, err
+	}
+	if ar.LastAdCid == "" {
+		return cid.Undef, fmt.Errorf("no advertisement has been published for %s yet", handle)
+	}
+
+	adCid, err := cid.Decode(ar.LastAdCid)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("could not decode last ad cid for %s: %w", handle, err)
+	}
+
+	providerID, retrievalAddresses, err := providerInfoForHandle(ar)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	parsedURLs := make([]*url.URL, 0, len(urls))
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return cid.Undef, fmt.Errorf("invalid indexer URL %q: %w", raw, err)
+		}
+		parsedURLs = append(parsedURLs, u)
+	}
+
+	sender := NewHTTPAnnounceSender(nil, defaultHTTPAnnounceUserAgent)
+	for u, err := range sender.sendTo(ctx, parsedURLs, adCid.String(), providerID, retrievalAddresses) {
+		if err != nil {
+			return cid.Undef, fmt.Errorf("announcing to %s: %w", u, err)
+		}
+	}
+
+	return adCid, nil
+}
+
+// ListMultihashes returns the multihashes that would be (or were) announced
+// for handle, running the same query the RegisterMultihashLister callback
+// uses.
+func (arEng *AutoretrieveEngine) ListMultihashes(ctx context.Context, handle string) ([]multihash.Multihash, error) {
+	return multihashesForHandle(arEng.db, handle)
+}
+
+// multihashesForHandle finds all content multihashes an autoretrieve handle
+// is due to announce: everything active since its last advertisement.
+func multihashesForHandle(db *gorm.DB, handle string) ([]multihash.Multihash, error) {
+	var ar Autoretrieve
+	if err := db.Find(&ar, "handle = ?", handle).Error; err != nil {
+		return nil, err
+	}
+
+	var newContents []util.Content
+	if err := db.Find(&newContents, "active = true and created_at >= ?", ar.LastAdvertisement).Error; err != nil {
+		return nil, err
+	}
+
+	multihashes := []multihash.Multihash{}
+	for _, content := range newContents {
+		multihashes = append(multihashes, content.Cid.CID.Hash())
+	}
+
+	return multihashes, nil
+}
+
+// providerInfoForHandle parses ar.Addresses into the providerID and
+// retrieval addresses NotifyPut expects.
+func providerInfoForHandle(ar Autoretrieve) (string, []string, error) {
+	retrievalAddresses := []string{}
+	providerID := ""
+	for _, fullAddr := range strings.Split(ar.Addresses, ",") {
+		arAddrInfo, err := peer.AddrInfoFromString(fullAddr)
+		if err != nil {
+			log.Errorf("could not parse multiaddress '%s': %s", fullAddr, err)
+			continue
+		}
+		providerID = arAddrInfo.ID.String()
+		retrievalAddresses = append(retrievalAddresses, arAddrInfo.Addrs[0].String())
+	}
+	if providerID == "" {
+		return "", nil, fmt.Errorf("no providerID for autoretrieve %s", ar.Handle)
+	}
+	if len(retrievalAddresses) == 0 {
+		return "", nil, fmt.Errorf("no retrieval addresses for autoretrieve %s", ar.Handle)
+	}
+
+	return providerID, retrievalAddresses, nil
+}
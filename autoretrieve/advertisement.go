@@ -0,0 +1,174 @@
+package autoretrieve
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"gorm.io/gorm"
+)
+
+// Advertisement is estuary's own durable audit record of every advertisement
+// an AutoretrieveEngine has published: the admin-facing source of truth for
+// ListAdvertisements (AnnounceLatest reads Autoretrieve.LastAdCid instead).
+// The actual published chain's Previous
+// pointer is computed and persisted by the embedded index-provider engine
+// itself (see WithDatastoreDir) — this table mirrors that chain but is not
+// what indexers resolve Previous against, so a DatastoreDir must be
+// configured for the real chain to survive a restart; this table alone
+// cannot make it resumable.
+type Advertisement struct {
+	gorm.Model
+
+	AutoretrieveHandle string `gorm:"index"`
+	AdCid              string `gorm:"uniqueIndex"`
+	PreviousAdCid      string
+	ContextID          string
+	EntriesCid         string
+	RemoveOp           bool
+}
+
+// recordAdvertisement writes a row for a newly published ad and updates the
+// owning Autoretrieve's LastAdvertisement/LastAdCid in the same transaction,
+// so the two can never disagree about what was last published. It also
+// advances the in-memory head pointer used to chain the next ad.
+func (arEng *AutoretrieveEngine) recordAdvertisement(handle string, adCid cid.Cid, contextID []byte, entriesCid string, removeOp bool) error {
+	arEng.headsMu.Lock()
+	previous := arEng.heads[handle]
+	arEng.headsMu.Unlock()
+
+	err := arEng.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&Advertisement{
+			AutoretrieveHandle: handle,
+			AdCid:              adCid.String(),
+			PreviousAdCid:      previous,
+			ContextID:          string(contextID),
+			EntriesCid:         entriesCid,
+			RemoveOp:           removeOp,
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&Autoretrieve{}).Where("handle = ?", handle).Updates(map[string]interface{}{
+			"last_advertisement": time.Now(),
+			"last_ad_cid":        adCid.String(),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	arEng.headsMu.Lock()
+	arEng.heads[handle] = adCid.String()
+	arEng.headsMu.Unlock()
+
+	return nil
+}
+
+// maxRecordAdvertisementAttempts bounds how many times recordAdvertisementDurable
+// retries a transient DB failure before falling back to a minimal dedupe-only
+// write.
+const maxRecordAdvertisementAttempts = 3
+
+// recordAdvertisementDurable retries recordAdvertisement a few times before
+// giving up. The ad has already been published to indexers by the time this
+// is called, so silently leaving LastAdvertisement/LastAdCid stale would make
+// the next tick pick up and re-announce the same content as a duplicate ad --
+// exactly the failure mode recordAdvertisement exists to prevent. If every
+// attempt fails, it falls back to updating just those two dedupe-critical
+// columns directly, so an ad never gets re-announced even though its
+// Advertisement audit row is then missing.
+func (arEng *AutoretrieveEngine) recordAdvertisementDurable(handle string, adCid cid.Cid, contextID []byte, entriesCid string, removeOp bool) error {
+	var err error
+	for attempt := 0; attempt < maxRecordAdvertisementAttempts; attempt++ {
+		if err = arEng.recordAdvertisement(handle, adCid, contextID, entriesCid, removeOp); err == nil {
+			return nil
+		}
+		log.Errorf("could not record advertisement for %s (attempt %d/%d): %s", handle, attempt+1, maxRecordAdvertisementAttempts, err)
+	}
+
+	if fallbackErr := arEng.db.Model(&Autoretrieve{}).Where("handle = ?", handle).Updates(map[string]interface{}{
+		"last_advertisement": time.Now(),
+		"last_ad_cid":        adCid.String(),
+	}).Error; fallbackErr != nil {
+		return fmt.Errorf("could not record advertisement for %s, and fallback update also failed: %w", handle, fallbackErr)
+	}
+
+	arEng.headsMu.Lock()
+	arEng.heads[handle] = adCid.String()
+	arEng.headsMu.Unlock()
+
+	log.Errorf("recorded %s's advertisement dedupe state without an Advertisement audit row after %d failed attempts: %s", handle, maxRecordAdvertisementAttempts, err)
+	return nil
+}
+
+// loadHeads scans the Advertisement table to rebuild the in-memory head
+// pointer (the most recently published ad CID) for every handle that has
+// published before, so our own PreviousAdCid bookkeeping matches the real
+// chain on restart. The real chain itself is only resumable if the engine
+// was built WithDatastoreDir; without it the embedded engine starts a fresh
+// chain and this value will disagree with what indexers actually see.
+func (arEng *AutoretrieveEngine) loadHeads() error {
+	var latest []Advertisement
+	err := arEng.db.Raw(`
+		SELECT a.* FROM advertisements a
+		INNER JOIN (
+			SELECT autoretrieve_handle, MAX(created_at) AS created_at
+			FROM advertisements
+			GROUP BY autoretrieve_handle
+		) head ON a.autoretrieve_handle = head.autoretrieve_handle AND a.created_at = head.created_at
+	`).Scan(&latest).Error
+	if err != nil {
+		return err
+	}
+
+	arEng.headsMu.Lock()
+	defer arEng.headsMu.Unlock()
+	for _, ad := range latest {
+		arEng.heads[ad.AutoretrieveHandle] = ad.AdCid
+	}
+	return nil
+}
+
+// NotifyRemove announces that the content behind contextID for handle has
+// been removed, so indexers drop it from their index instead of it silently
+// going stale.
+func (arEng *AutoretrieveEngine) NotifyRemove(ctx context.Context, handle string, contextID []byte) (cid.Cid, error) {
+	var ar Autoretrieve
+	if err := arEng.db.Find(&ar, "handle = ?", handle).Error; err != nil {
+		return cid.Undef, err
+	}
+
+	providerIDStr, _, err := providerInfoForHandle(ar)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	providerID, err := peer.Decode(providerIDStr)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("invalid provider id for %s: %w", handle, err)
+	}
+
+	adCid, err := arEng.Engine.NotifyRemove(ctx, providerID, contextID)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	if err := arEng.recordAdvertisement(handle, adCid, contextID, "", true); err != nil {
+		return cid.Undef, fmt.Errorf("could not record removal advertisement: %w", err)
+	}
+
+	return adCid, nil
+}
+
+// ListAdvertisements returns every advertisement published for handle at or
+// after since, oldest first.
+func (arEng *AutoretrieveEngine) ListAdvertisements(handle string, since time.Time) ([]Advertisement, error) {
+	var ads []Advertisement
+	if err := arEng.db.Order("created_at asc").Find(&ads, "autoretrieve_handle = ? and created_at >= ?", handle, since).Error; err != nil {
+		return nil, err
+	}
+	return ads, nil
+}
@@ -0,0 +1,143 @@
+package autoretrieve
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	// one named in-memory DB per test, so rows from one test don't collide
+	// with another's on the unique handle index
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open test db: %s", err)
+	}
+	if err := db.AutoMigrate(&Autoretrieve{}, &Advertisement{}); err != nil {
+		t.Fatalf("could not migrate test db: %s", err)
+	}
+	return db
+}
+
+func mustDecodeCid(t *testing.T, s string) cid.Cid {
+	t.Helper()
+	c, err := cid.Decode(s)
+	if err != nil {
+		t.Fatalf("could not decode test cid %q: %s", s, err)
+	}
+	return c
+}
+
+// TestRecordAdvertisementAndLoadHeadsAcrossRestart exercises recordAdvertisement's
+// head-chaining, then simulates a process restart (a fresh AutoretrieveEngine
+// over the same DB) and checks loadHeads reconstructs the same in-memory head.
+func TestRecordAdvertisementAndLoadHeadsAcrossRestart(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.Create(&Autoretrieve{Handle: "ar1"}).Error; err != nil {
+		t.Fatalf("could not create test autoretrieve: %s", err)
+	}
+
+	firstAd := mustDecodeCid(t, "bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi")
+	secondAd := mustDecodeCid(t, "bafkreifzjut3te2nhyekklss27nh3k72ysco7y32koao5eei66wof36n5e")
+
+	arEng := &AutoretrieveEngine{db: db, heads: make(map[string]string)}
+
+	if err := arEng.recordAdvertisement("ar1", firstAd, []byte("ar1"), "", false); err != nil {
+		t.Fatalf("could not record first advertisement: %s", err)
+	}
+	if got := arEng.heads["ar1"]; got != firstAd.String() {
+		t.Fatalf("expected in-memory head %s after the first advertisement, got %q", firstAd, got)
+	}
+
+	if err := arEng.recordAdvertisement("ar1", secondAd, []byte("ar1"), "", false); err != nil {
+		t.Fatalf("could not record second advertisement: %s", err)
+	}
+	if got := arEng.heads["ar1"]; got != secondAd.String() {
+		t.Fatalf("expected in-memory head %s, got %s", secondAd, got)
+	}
+
+	var ads []Advertisement
+	if err := db.Order("created_at asc").Find(&ads, "autoretrieve_handle = ?", "ar1").Error; err != nil {
+		t.Fatalf("could not list advertisements: %s", err)
+	}
+	if len(ads) != 2 {
+		t.Fatalf("expected 2 advertisement rows, got %d", len(ads))
+	}
+	if ads[1].PreviousAdCid != firstAd.String() {
+		t.Fatalf("expected second ad's PreviousAdCid to chain onto the first, got %q", ads[1].PreviousAdCid)
+	}
+
+	var ar Autoretrieve
+	if err := db.Find(&ar, "handle = ?", "ar1").Error; err != nil {
+		t.Fatalf("could not reload autoretrieve: %s", err)
+	}
+	if ar.LastAdCid != secondAd.String() {
+		t.Fatalf("expected LastAdCid %s, got %s", secondAd, ar.LastAdCid)
+	}
+
+	// simulate a restart: a brand new AutoretrieveEngine over the same DB,
+	// with an empty in-memory heads map until loadHeads rebuilds it.
+	restarted := &AutoretrieveEngine{db: db, heads: make(map[string]string)}
+	if err := restarted.loadHeads(); err != nil {
+		t.Fatalf("could not load heads after restart: %s", err)
+	}
+	if got := restarted.heads["ar1"]; got != secondAd.String() {
+		t.Fatalf("expected restarted engine to resume head %s, got %s", secondAd, got)
+	}
+
+	// a third ad published after "restart" should chain onto the reloaded head.
+	thirdAd := mustDecodeCid(t, "bafkreiabmx2l6pzxxjf2oxfkqceybwz6fwrnqlcxpkrcxcoaqzbeke3v6m")
+	if err := restarted.recordAdvertisement("ar1", thirdAd, []byte("ar1"), "", false); err != nil {
+		t.Fatalf("could not record advertisement after restart: %s", err)
+	}
+
+	var thirdRow Advertisement
+	if err := db.Find(&thirdRow, "autoretrieve_handle = ? and ad_cid = ?", "ar1", thirdAd.String()).Error; err != nil {
+		t.Fatalf("could not reload third advertisement: %s", err)
+	}
+	if thirdRow.PreviousAdCid != secondAd.String() {
+		t.Fatalf("expected third ad to chain onto the pre-restart head %s, got %q", secondAd, thirdRow.PreviousAdCid)
+	}
+}
+
+// TestRecordAdvertisementDurableFallsBackOnPersistentFailure checks that once
+// retries are exhausted, the dedupe-critical columns are still updated so a
+// DB hiccup can't cause the same content to be re-announced next tick.
+func TestRecordAdvertisementDurableFallsBackOnPersistentFailure(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.Create(&Autoretrieve{Handle: "ar1"}).Error; err != nil {
+		t.Fatalf("could not create test autoretrieve: %s", err)
+	}
+
+	// Drop the advertisements table so recordAdvertisement's Create fails
+	// every attempt, forcing recordAdvertisementDurable into its fallback.
+	if err := db.Migrator().DropTable(&Advertisement{}); err != nil {
+		t.Fatalf("could not drop advertisements table: %s", err)
+	}
+
+	arEng := &AutoretrieveEngine{db: db, heads: make(map[string]string)}
+	adCid := mustDecodeCid(t, "bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi")
+
+	before := time.Now()
+	if err := arEng.recordAdvertisementDurable("ar1", adCid, []byte("ar1"), "", false); err != nil {
+		t.Fatalf("expected the fallback write to succeed, got %s", err)
+	}
+
+	var ar Autoretrieve
+	if err := db.Find(&ar, "handle = ?", "ar1").Error; err != nil {
+		t.Fatalf("could not reload autoretrieve: %s", err)
+	}
+	if ar.LastAdCid != adCid.String() {
+		t.Fatalf("expected fallback to set LastAdCid to %s, got %s", adCid, ar.LastAdCid)
+	}
+	if ar.LastAdvertisement.Before(before) {
+		t.Fatalf("expected fallback to bump LastAdvertisement, got %s", ar.LastAdvertisement)
+	}
+	if got := arEng.heads["ar1"]; got != adCid.String() {
+		t.Fatalf("expected fallback to update the in-memory head to %s, got %s", adCid, got)
+	}
+}
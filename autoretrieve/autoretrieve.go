@@ -4,12 +4,12 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/application-research/estuary/util"
 	provider "github.com/filecoin-project/index-provider"
-	"github.com/filecoin-project/index-provider/metadata"
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/libp2p/go-libp2p-core/peer"
@@ -26,8 +26,23 @@ type Autoretrieve struct {
 	Token             string `gorm:"unique"`
 	LastConnection    time.Time
 	LastAdvertisement time.Time
+	LastAdCid         string
 	PrivateKey        string `gorm:"unique"`
 	Addresses         string
+
+	// Protocols is a comma-separated list of retrieval transports this
+	// autoretrieve advertises, e.g. "bitswap,graphsync,http". Empty means
+	// bitswap-only, for backwards compatibility with rows created before
+	// this column existed.
+	Protocols string
+
+	// PieceCid is required when Protocols includes graphsync, identifying
+	// the piece the GraphsyncFilecoinV1 transport serves.
+	PieceCid string
+
+	// HTTPBaseURL is required when Protocols includes http, the base URL
+	// this autoretrieve serves retrievals from.
+	HTTPBaseURL string
 }
 
 type HeartbeatAutoretrieveResponse struct {
@@ -65,41 +80,112 @@ func (m *SimpleEstuaryMhIterator) Next() (multihash.Multihash, error) {
 	return nil, io.EOF
 }
 
+// defaultIndexerMultiaddr is the indexer estuary announces to when an
+// EngineConfig doesn't specify any IndexerAddrs.
+const defaultIndexerMultiaddr = "/ip4/127.0.0.1/tcp/3003/p2p/12D3KooWChQyVH7a3iR3o8kmdYwXiHf2v3tXQWhSCS9j8NbLVQ9o" //TODO: need to adjust p2p addr
+
+// defaultIndexerTopic is the pubsub topic estuary announces to when an
+// EngineConfig doesn't specify a Topic.
+const defaultIndexerTopic = "/indexer/ingest/mainnet"
+
+// EngineConfig configures the indexers an AutoretrieveEngine announces to
+// and how it reaches them, loaded from Estuary's main config file.
+type EngineConfig struct {
+	// IndexerAddrs are the indexer peers to direct-connect over gossipsub.
+	// Defaults to estuary's built-in indexer if empty.
+	IndexerAddrs []multiaddr.Multiaddr
+	// Topic is the pubsub topic advertisements are announced on. Defaults
+	// to "/indexer/ingest/mainnet" if empty.
+	Topic string
+	// PublisherKind selects which transport(s) carry advertisements to
+	// indexers: DataTransferPublisher (gossipsub), HTTPPublisher, or
+	// BothPublishers.
+	PublisherKind PublisherKind
+	// HTTPAnnounceURLs are the indexer announce endpoints used when
+	// PublisherKind is HTTPPublisher or BothPublishers.
+	HTTPAnnounceURLs []*url.URL
+	// HTTPUserAgent is the User-Agent sent with HTTP announcements.
+	HTTPUserAgent string
+	// DatastoreDir persists the embedded index-provider engine's own
+	// advertisement chain so its Previous-ad pointer survives a restart.
+	// If empty, the engine falls back to an in-memory datastore and the
+	// published chain will NOT be resumable across restarts, regardless of
+	// what the local Advertisement table records.
+	DatastoreDir string
+}
+
 // newIndexProvider creates a new index-provider engine to send announcements to storetheindex
 // this needs to keep running continuously because storetheindex
 // will come to fetch advertisements "when it feels like it"
-func NewAutoretrieveEngine(stopCh chan struct{}, tickInterval time.Duration, db *gorm.DB) (*AutoretrieveEngine, error) {
+func NewAutoretrieveEngine(stopCh chan struct{}, tickInterval time.Duration, db *gorm.DB, cfg EngineConfig) (*AutoretrieveEngine, error) {
 	host, err := libp2p.New()
 	if err != nil {
 		return nil, err
 	}
-	topic := "/indexer/ingest/mainnet"
-	indexerMultiaddr, err := multiaddr.NewMultiaddr("/ip4/127.0.0.1/tcp/3003/p2p/12D3KooWChQyVH7a3iR3o8kmdYwXiHf2v3tXQWhSCS9j8NbLVQ9o") //TODO: need to adjust p2p addr
-	if err != nil {
-		return nil, err
+
+	opts := []Option{
+		WithHost(host), // need to be localhost/estuary
+		WithPublisherKind(cfg.PublisherKind),
 	}
-	indexerAddrinfo, err := peer.AddrInfosFromP2pAddrs(indexerMultiaddr)
-	if err != nil {
-		return nil, err
+
+	var indexerAddrInfos []peer.AddrInfo
+	if cfg.PublisherKind != HTTPPublisher {
+		topic := cfg.Topic
+		if topic == "" {
+			topic = defaultIndexerTopic
+		}
+
+		indexerAddrs := cfg.IndexerAddrs
+		if len(indexerAddrs) == 0 {
+			defaultAddr, err := multiaddr.NewMultiaddr(defaultIndexerMultiaddr)
+			if err != nil {
+				return nil, err
+			}
+			indexerAddrs = []multiaddr.Multiaddr{defaultAddr}
+		}
+
+		for _, addr := range indexerAddrs {
+			infos, err := peer.AddrInfosFromP2pAddrs(addr)
+			if err != nil {
+				log.Errorf("could not parse indexer multiaddr '%s': %s", addr, err)
+				continue
+			}
+			indexerAddrInfos = append(indexerAddrInfos, infos...)
+		}
+		if len(indexerAddrInfos) == 0 {
+			return nil, fmt.Errorf("no valid indexer addresses configured")
+		}
+
+		pubG, err := pubsub.NewGossipSub(context.Background(), host,
+			pubsub.WithDirectConnectTicks(1),
+			pubsub.WithDirectPeers(indexerAddrInfos),
+		)
+		if err != nil {
+			return nil, err
+		}
+		pubT, err := pubG.Join(topic)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, WithTopic(pubT), WithTopicName(topic))
 	}
-	pubG, err := pubsub.NewGossipSub(context.Background(), host,
-		pubsub.WithDirectConnectTicks(1),
-		pubsub.WithDirectPeers(indexerAddrinfo),
-	)
-	if err != nil {
-		return nil, err
+
+	if cfg.PublisherKind != DataTransferPublisher {
+		opts = append(opts, WithHTTPAnnounceSender(NewHTTPAnnounceSender(cfg.HTTPAnnounceURLs, cfg.HTTPUserAgent)))
 	}
-	pubT, err := pubG.Join(topic)
-	if err != nil {
-		return nil, err
+
+	if cfg.DatastoreDir != "" {
+		opts = append(opts, WithDatastoreDir(cfg.DatastoreDir))
+	} else {
+		log.Warnf("no DatastoreDir configured: the advertisement chain will not survive a restart")
 	}
 
-	newEngine, err := New(
-		WithTopic(pubT),      // TODO: remove, testing
-		WithTopicName(topic), // TODO: remove, testing
-		WithHost(host),       // need to be localhost/estuary
-		WithPublisherKind(DataTransferPublisher),
-	)
+	if err := migrateProtocolsDefault(db); err != nil {
+		return nil, fmt.Errorf("could not migrate autoretrieve protocols: %w", err)
+	}
+
+	newEngine, err := New(opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -108,31 +194,13 @@ func NewAutoretrieveEngine(stopCh chan struct{}, tickInterval time.Duration, db
 	// this needs to keep running continuously because storetheindex
 	// will come to fetch for advertisements "when it feels like it"
 	newEngine.RegisterMultihashLister(func(ctx context.Context, contextID []byte) (provider.MultihashIterator, error) {
+		arHandle := string(contextID) // contextID is the autoretrieve handle
 
-		arHandle := contextID // contextID is the autoretrieve handle
-		if err != nil {
-			return nil, err
-		}
-
-		var ar Autoretrieve
-		// get the autoretrieve entry from the database
-		err = db.Find(&ar, "handle = ?", arHandle).Error
-		if err != nil {
-			return nil, err
-		}
-
-		var newContents []util.Content
-		// find all new multihashes since the last time we advertised for this autoretrieve server
-		err = db.Find(&newContents, "active = true and created_at >= ?", ar.LastAdvertisement).Error
+		multihashes, err := multihashesForHandle(db, arHandle)
 		if err != nil {
 			return nil, err
 		}
 
-		multihashes := []multihash.Multihash{}
-		for _, content := range newContents {
-			multihashes = append(multihashes, content.Cid.CID.Hash())
-		}
-
 		return &SimpleEstuaryMhIterator{
 			Mh: multihashes,
 		}, nil
@@ -141,6 +209,13 @@ func NewAutoretrieveEngine(stopCh chan struct{}, tickInterval time.Duration, db
 	newEngine.stopCh = stopCh
 	newEngine.tickInterval = tickInterval
 	newEngine.db = db
+	newEngine.indexerAddrInfos = indexerAddrInfos
+
+	// rebuild the per-handle head pointers from the Advertisement table so
+	// the next ad published for each handle chains onto the right previous ad
+	if err := newEngine.loadHeads(); err != nil {
+		return nil, fmt.Errorf("could not load advertisement heads: %w", err)
+	}
 
 	// start engine
 	newEngine.Start(context.Background())
@@ -178,29 +253,17 @@ func (arEng *AutoretrieveEngine) Run() {
 			}
 		}
 
+		arEng.recordIndexerReachability()
+
 		log.Infof("announcing new CIDs to %d autoretrieve servers", len(autoretrieves))
 		// send announcement with new CIDs for each autoretrieve server
 		for _, ar := range autoretrieves {
 
 			newContextID = []byte(ar.Handle)
 
-			retrievalAddresses := []string{}
-			providerID := ""
-			for _, fullAddr := range strings.Split(ar.Addresses, ",") {
-				arAddrInfo, err := peer.AddrInfoFromString(fullAddr)
-				if err != nil {
-					log.Errorf("could not parse multiaddress '%s': %s", fullAddr, err)
-					continue
-				}
-				providerID = arAddrInfo.ID.String()
-				retrievalAddresses = append(retrievalAddresses, arAddrInfo.Addrs[0].String())
-			}
-			if providerID == "" {
-				log.Errorf("no providerID for autoretrieve %s, skipping", ar.Handle)
-				continue
-			}
-			if len(retrievalAddresses) == 0 {
-				log.Errorf("no retrieval addresses for autoretrieve %s, skipping", ar.Handle)
+			providerID, retrievalAddresses, err := providerInfoForHandle(ar)
+			if err != nil {
+				log.Errorf("%s", err)
 				continue
 			}
 
@@ -216,17 +279,56 @@ func (arEng *AutoretrieveEngine) Run() {
 			}
 			log.Debugf("found %d new CIDs, announcing", newContentsCount)
 
+			adMetadata, err := metadataForAutoretrieve(ar)
+			if err != nil {
+				log.Errorf("could not build advertisement metadata: %s", err)
+				continue
+			}
+
 			log.Infof("sending announcement to %s", ar.Handle)
-			adCid, err := arEng.NotifyPut(context.Background(), newContextID, providerID, retrievalAddresses, metadata.New(metadata.Bitswap{}))
+			adCid, err := arEng.NotifyPut(context.Background(), newContextID, providerID, retrievalAddresses, adMetadata)
 			if err != nil {
 				log.Errorf("could not announce new CIDs: %s", err)
+				if arEng.publisherKind != HTTPPublisher {
+					for _, info := range arEng.indexerAddrInfos {
+						announceTotal.WithLabelValues(info.ID.String(), "failure").Inc()
+					}
+				}
 				continue
 			}
 
-			// update lastAdvertisement time on database
-			if err := arEng.db.Model(Autoretrieve{}).UpdateColumn("lastAdvertisement", time.Now()).Error; err != nil {
-				log.Errorf("unable to update advertisement time on database: %s", err)
-				return
+			// the embedded engine already published adCid over gossipsub if
+			// configured to; record one success per direct-connected indexer
+			// so the default gossipsub-only deployment gets announce counts
+			// too, not just the HTTP path below.
+			if arEng.publisherKind != HTTPPublisher {
+				for _, info := range arEng.indexerAddrInfos {
+					announceTotal.WithLabelValues(info.ID.String(), "success").Inc()
+				}
+			}
+
+			// also send it straight to any configured HTTP indexer announce
+			// URLs. A slow or unreachable indexer URL only counts as a
+			// failure for itself, never stops the rest.
+			if arEng.publisherKind != DataTransferPublisher {
+				for indexerURL, sendErr := range arEng.httpSender.SendResults(context.Background(), adCid.String(), providerID, retrievalAddresses) {
+					result := "success"
+					if sendErr != nil {
+						result = "failure"
+						log.Errorf("could not send HTTP announcement to %s: %s", indexerURL, sendErr)
+					}
+					announceTotal.WithLabelValues(indexerURL, result).Inc()
+				}
+			}
+
+			// durably record the ad and update lastAdvertisement/lastAdCid
+			// in the same transaction, so a crash can't leave them
+			// disagreeing; retries transient failures and falls back to a
+			// dedupe-only write so a DB hiccup can't cause the next tick to
+			// re-announce content that's already been published.
+			if err := arEng.recordAdvertisementDurable(ar.Handle, adCid, newContextID, "", false); err != nil {
+				log.Errorf("could not record advertisement: %s", err)
+				continue
 			}
 
 			log.Infof("announced new CIDs: %s", adCid)
@@ -256,35 +358,43 @@ func validateAddresses(addresses []string) []string {
 	return invalidAddresses
 }
 
-func ValidatePeerInfo(privKeyStr string, addresses []string) (*peer.AddrInfo, error) {
+// ValidatePeerInfo validates a registering autoretrieve's peer info and the
+// set of retrieval protocols it's asking to advertise, returning the
+// addrInfo built from its addresses and the normalized protocol list.
+func ValidatePeerInfo(privKeyStr string, addresses []string, protocols []string, pieceCid string, httpBaseURL string) (*peer.AddrInfo, []string, error) {
 	// check if peerid is correct
 	privateKey, err := stringToPrivKey(privKeyStr)
 	if err != nil {
-		return nil, fmt.Errorf("unable to decode private key: %s", err)
+		return nil, nil, fmt.Errorf("unable to decode private key: %s", err)
 	}
 	_, err = peer.IDFromPrivateKey(privateKey)
 	if err != nil {
-		return nil, fmt.Errorf("invalid peer information: %s", err)
+		return nil, nil, fmt.Errorf("invalid peer information: %s", err)
 	}
 
 	if len(addresses) == 0 || addresses[0] == "" {
-		return nil, fmt.Errorf("no addresses provided")
+		return nil, nil, fmt.Errorf("no addresses provided")
 	}
 
 	// check if multiaddresses formats are correct
 	invalidAddrs := validateAddresses(addresses)
 	if len(invalidAddrs) != 0 {
-		return nil, fmt.Errorf("invalid address(es): %s", strings.Join(invalidAddrs, ", "))
+		return nil, nil, fmt.Errorf("invalid address(es): %s", strings.Join(invalidAddrs, ", "))
 	}
 
 	// any of the multiaddresses of the peer should work to get addrInfo
 	// we get the first one
 	addrInfo, err := peer.AddrInfoFromString(addresses[0])
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	normalizedProtocols, err := ValidateProtocols(protocols, pieceCid, httpBaseURL)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return addrInfo, nil
+	return addrInfo, normalizedProtocols, nil
 }
 
 func stringToPrivKey(privKeyStr string) (crypto.PrivKey, error) {
@@ -0,0 +1,176 @@
+package autoretrieve
+
+import (
+	"sync"
+	"time"
+
+	ipniengine "github.com/filecoin-project/index-provider/engine"
+	"github.com/ipfs/go-datastore"
+	dsleveldb "github.com/ipfs/go-ds-leveldb"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"gorm.io/gorm"
+)
+
+// PublisherKind selects how an AutoretrieveEngine carries advertisements to
+// indexers.
+type PublisherKind int
+
+const (
+	// DataTransferPublisher announces over the libp2p gossipsub topic, the
+	// original and default transport.
+	DataTransferPublisher PublisherKind = iota
+	// HTTPPublisher announces by POSTing directly to one or more indexer
+	// announce URLs, bypassing gossipsub entirely.
+	HTTPPublisher
+	// BothPublishers announces over gossipsub and HTTP for every ad.
+	BothPublishers
+)
+
+// AutoretrieveEngine wraps an index-provider engine with the bookkeeping
+// estuary needs to drive it from the ticker loop in Run: the database used
+// to find online autoretrieve servers and new content, and whichever
+// publisher(s) are configured to carry advertisements to indexers.
+type AutoretrieveEngine struct {
+	*ipniengine.Engine
+
+	host          host.Host
+	topic         *pubsub.Topic
+	topicName     string
+	publisherKind PublisherKind
+	httpSender    *HTTPAnnounceSender
+
+	// datastore backs the embedded engine's own advertisement chain. It
+	// must be persistent (not the in-memory default) for the engine's
+	// Previous-ad pointer to survive a restart; see WithDatastoreDir.
+	datastore datastore.Batching
+
+	stopCh       chan struct{}
+	tickInterval time.Duration
+	db           *gorm.DB
+
+	// indexerAddrInfos are the indexer peers this engine direct-connects to
+	// over gossipsub, used to report per-indexer reachability in Run.
+	indexerAddrInfos []peer.AddrInfo
+
+	// heads tracks the most recently published ad CID per autoretrieve
+	// handle, so the next ad's PreviousAdCid chains correctly. It's
+	// rebuilt from the Advertisement table on startup by loadHeads.
+	headsMu sync.Mutex
+	heads   map[string]string
+}
+
+// Option configures an AutoretrieveEngine built with New.
+type Option func(*AutoretrieveEngine) error
+
+// WithHost sets the libp2p host the engine announces as.
+func WithHost(h host.Host) Option {
+	return func(ae *AutoretrieveEngine) error {
+		ae.host = h
+		return nil
+	}
+}
+
+// WithTopic sets the pubsub topic advertisements are published on.
+func WithTopic(t *pubsub.Topic) Option {
+	return func(ae *AutoretrieveEngine) error {
+		ae.topic = t
+		return nil
+	}
+}
+
+// WithTopicName records the name of the pubsub topic set via WithTopic.
+func WithTopicName(name string) Option {
+	return func(ae *AutoretrieveEngine) error {
+		ae.topicName = name
+		return nil
+	}
+}
+
+// WithPublisherKind selects which transport(s) Run uses to carry
+// advertisements to indexers once NotifyPut has produced an ad CID.
+func WithPublisherKind(kind PublisherKind) Option {
+	return func(ae *AutoretrieveEngine) error {
+		ae.publisherKind = kind
+		return nil
+	}
+}
+
+// WithHTTPAnnounceSender configures the sender used whenever the engine's
+// PublisherKind is HTTPPublisher or BothPublishers.
+func WithHTTPAnnounceSender(s *HTTPAnnounceSender) Option {
+	return func(ae *AutoretrieveEngine) error {
+		ae.httpSender = s
+		return nil
+	}
+}
+
+// WithDatastoreDir opens a persistent leveldb datastore at dir and uses it
+// to back the embedded index-provider engine's own advertisement chain.
+// Without this, the engine falls back to an in-memory datastore, so its
+// Previous-ad pointer resets on every restart regardless of what
+// Advertisement records estuary itself has kept.
+func WithDatastoreDir(dir string) Option {
+	return func(ae *AutoretrieveEngine) error {
+		ds, err := dsleveldb.NewDatastore(dir, nil)
+		if err != nil {
+			return err
+		}
+		ae.datastore = ds
+		return nil
+	}
+}
+
+// New builds an AutoretrieveEngine around a fresh index-provider engine.
+func New(opts ...Option) (*AutoretrieveEngine, error) {
+	ae := &AutoretrieveEngine{
+		heads: make(map[string]string),
+	}
+	for _, opt := range opts {
+		if err := opt(ae); err != nil {
+			return nil, err
+		}
+	}
+
+	var engineOpts []ipniengine.Option
+	if ae.host != nil {
+		engineOpts = append(engineOpts, ipniengine.WithHost(ae.host))
+	}
+	if ae.topic != nil {
+		engineOpts = append(engineOpts, ipniengine.WithTopic(ae.topic))
+	}
+	if ae.topicName != "" {
+		engineOpts = append(engineOpts, ipniengine.WithTopicName(ae.topicName))
+	}
+	if ae.datastore != nil {
+		engineOpts = append(engineOpts, ipniengine.WithDatastore(ae.datastore))
+	}
+
+	eng, err := ipniengine.New(engineOpts...)
+	if err != nil {
+		return nil, err
+	}
+	ae.Engine = eng
+
+	return ae, nil
+}
+
+// recordIndexerReachability reports each configured gossipsub indexer's
+// connectedness as a Prometheus gauge, so one unreachable indexer shows up
+// without hiding whether the rest are still being kept in sync. This is a
+// reachability probe only — it does not imply an advertisement was actually
+// sent that tick, so it's kept separate from announceTotal.
+func (ae *AutoretrieveEngine) recordIndexerReachability() {
+	if ae.host == nil {
+		return
+	}
+	for _, info := range ae.indexerAddrInfos {
+		connected := float64(0)
+		if ae.host.Network().Connectedness(info.ID) == network.Connected {
+			connected = 1
+		}
+		indexerConnected.WithLabelValues(info.ID.String()).Set(connected)
+	}
+}
@@ -0,0 +1,98 @@
+package autoretrieve
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// AdminHandlers exposes AutoretrieveEngine's admin operations (AnnounceLatest,
+// ListMultihashes, AnnounceLatestHTTP) as http.HandlerFuncs. Estuary's admin
+// router lives outside this package (and isn't present in this tree), so
+// wiring these onto an actual route (e.g. under /admin/autoretrieve/...) is
+// left to whoever owns that package; this only makes the operations
+// reachable over HTTP in a form that's trivial to mount there.
+type AdminHandlers struct {
+	arEng *AutoretrieveEngine
+}
+
+// NewAdminHandlers builds AdminHandlers around arEng.
+func NewAdminHandlers(arEng *AutoretrieveEngine) *AdminHandlers {
+	return &AdminHandlers{arEng: arEng}
+}
+
+// AnnounceLatestHandler re-publishes the latest advertisement for the handle
+// given in the "handle" query parameter.
+func (h *AdminHandlers) AnnounceLatestHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handle := r.URL.Query().Get("handle")
+		if handle == "" {
+			http.Error(w, "missing handle parameter", http.StatusBadRequest)
+			return
+		}
+
+		adCid, err := h.arEng.AnnounceLatest(r.Context(), handle)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, map[string]string{"cid": adCid.String()})
+	}
+}
+
+// AnnounceLatestHTTPHandler sends the latest advertisement for the handle
+// given in the "handle" query parameter to the ad-hoc indexer URLs given as
+// repeated "url" query parameters.
+func (h *AdminHandlers) AnnounceLatestHTTPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handle := r.URL.Query().Get("handle")
+		if handle == "" {
+			http.Error(w, "missing handle parameter", http.StatusBadRequest)
+			return
+		}
+		urls := r.URL.Query()["url"]
+		if len(urls) == 0 {
+			http.Error(w, "missing url parameter(s)", http.StatusBadRequest)
+			return
+		}
+
+		adCid, err := h.arEng.AnnounceLatestHTTP(r.Context(), handle, urls)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, map[string]string{"cid": adCid.String()})
+	}
+}
+
+// ListMultihashesHandler lists the multihashes due to be announced for the
+// handle given in the "handle" query parameter.
+func (h *AdminHandlers) ListMultihashesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handle := r.URL.Query().Get("handle")
+		if handle == "" {
+			http.Error(w, "missing handle parameter", http.StatusBadRequest)
+			return
+		}
+
+		multihashes, err := h.arEng.ListMultihashes(r.Context(), handle)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		strs := make([]string, len(multihashes))
+		for i, mh := range multihashes {
+			strs[i] = mh.String()
+		}
+		writeJSON(w, map[string][]string{"multihashes": strs})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("failed writing admin response: %s", err)
+	}
+}
@@ -0,0 +1,146 @@
+package autoretrieve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHTTPAnnounceUserAgent = "estuary-autoretrieve"
+	defaultHTTPAnnounceTimeout   = 10 * time.Second
+	maxHTTPAnnounceAttempts      = 3
+)
+
+// httpAnnouncement is the payload POSTed to an indexer's announce endpoint,
+// matching the shape storetheindex expects: the advertisement CID and the
+// provider's multiaddrs (each with the /p2p/<peer.ID> component appended so
+// the indexer knows who to sync the ad from).
+type httpAnnouncement struct {
+	Cid   string   `json:"Cid"`
+	Addrs []string `json:"Addrs"`
+}
+
+// HTTPAnnounceSender posts advertisement announcements directly to one or
+// more indexer announce URLs (e.g. https://cid.contact/announce), as an
+// alternative or complement to gossipsub.
+type HTTPAnnounceSender struct {
+	urls      []*url.URL
+	userAgent string
+	client    *http.Client
+}
+
+// NewHTTPAnnounceSender builds a sender that fans out to every URL in urls.
+// An empty userAgent falls back to a sensible estuary default.
+func NewHTTPAnnounceSender(urls []*url.URL, userAgent string) *HTTPAnnounceSender {
+	if userAgent == "" {
+		userAgent = defaultHTTPAnnounceUserAgent
+	}
+	return &HTTPAnnounceSender{
+		urls:      urls,
+		userAgent: userAgent,
+		client:    &http.Client{Timeout: defaultHTTPAnnounceTimeout},
+	}
+}
+
+// Send announces adCid to every configured indexer URL concurrently,
+// advertising retrievalAddrs with providerID appended as a /p2p component.
+// Each URL gets its own retries with backoff on 5xx; once all URLs have
+// finished, Send returns one of the errors if any URL failed (which one is
+// not defined when several fail), but a slow or unreachable indexer never
+// stops the others from being announced to.
+func (s *HTTPAnnounceSender) Send(ctx context.Context, adCid string, providerID string, retrievalAddrs []string) error {
+	for u, err := range s.sendTo(ctx, s.urls, adCid, providerID, retrievalAddrs) {
+		if err != nil {
+			return fmt.Errorf("announcing to %s: %w", u, err)
+		}
+	}
+	return nil
+}
+
+// SendResults is like Send, but reports the per-URL outcome instead of
+// stopping at the first error, so callers can surface per-indexer
+// success/failure (e.g. as metrics) without one bad indexer masking the rest.
+func (s *HTTPAnnounceSender) SendResults(ctx context.Context, adCid string, providerID string, retrievalAddrs []string) map[string]error {
+	return s.sendTo(ctx, s.urls, adCid, providerID, retrievalAddrs)
+}
+
+// sendTo is like Send but targets an explicit set of URLs instead of the
+// sender's configured ones, useful for ad-hoc announcements to indexers
+// that aren't permanently registered. It returns every URL's outcome keyed
+// by URL string.
+func (s *HTTPAnnounceSender) sendTo(ctx context.Context, urls []*url.URL, adCid string, providerID string, retrievalAddrs []string) map[string]error {
+	addrs := make([]string, 0, len(retrievalAddrs))
+	for _, addr := range retrievalAddrs {
+		addrs = append(addrs, fmt.Sprintf("%s/p2p/%s", addr, providerID))
+	}
+
+	results := make(map[string]error, len(urls))
+
+	body, err := json.Marshal(httpAnnouncement{Cid: adCid, Addrs: addrs})
+	if err != nil {
+		for _, u := range urls {
+			results[u.String()] = fmt.Errorf("could not marshal announcement: %w", err)
+		}
+		return results
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, u := range urls {
+		wg.Add(1)
+		go func(u *url.URL) {
+			defer wg.Done()
+			err := s.postWithRetry(ctx, u, body)
+			mu.Lock()
+			results[u.String()] = err
+			mu.Unlock()
+		}(u)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (s *HTTPAnnounceSender) postWithRetry(ctx context.Context, u *url.URL, body []byte) error {
+	var lastErr error
+	for attempt := 0; attempt < maxHTTPAnnounceAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt) * time.Second):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", s.userAgent)
+
+		resp, err := s.client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode >= 500:
+			lastErr = fmt.Errorf("indexer returned %s", resp.Status)
+			continue
+		case resp.StatusCode >= 400:
+			return fmt.Errorf("indexer returned %s", resp.Status)
+		default:
+			return nil
+		}
+	}
+	return lastErr
+}
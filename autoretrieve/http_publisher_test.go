@@ -0,0 +1,92 @@
+package autoretrieve
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPostWithRetry(t *testing.T) {
+	parseURL := func(t *testing.T, rawURL string) *url.URL {
+		t.Helper()
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatalf("could not parse test server URL: %s", err)
+		}
+		return u
+	}
+
+	t.Run("succeeds on first attempt", func(t *testing.T) {
+		var attempts int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&attempts, 1)
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer srv.Close()
+
+		s := NewHTTPAnnounceSender(nil, "")
+		if err := s.postWithRetry(context.Background(), parseURL(t, srv.URL), []byte("{}")); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		if attempts != 1 {
+			t.Fatalf("expected 1 attempt, got %d", attempts)
+		}
+	})
+
+	t.Run("does not retry on 4xx", func(t *testing.T) {
+		var attempts int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&attempts, 1)
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer srv.Close()
+
+		s := NewHTTPAnnounceSender(nil, "")
+		if err := s.postWithRetry(context.Background(), parseURL(t, srv.URL), []byte("{}")); err == nil {
+			t.Fatal("expected an error for a 4xx response")
+		}
+		if attempts != 1 {
+			t.Fatalf("expected no retries on 4xx, got %d attempts", attempts)
+		}
+	})
+
+	t.Run("retries on 5xx up to the attempt limit", func(t *testing.T) {
+		var attempts int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&attempts, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer srv.Close()
+
+		s := NewHTTPAnnounceSender(nil, "")
+		if err := s.postWithRetry(context.Background(), parseURL(t, srv.URL), []byte("{}")); err == nil {
+			t.Fatal("expected an error after exhausting retries on 5xx")
+		}
+		if attempts != maxHTTPAnnounceAttempts {
+			t.Fatalf("expected %d attempts, got %d", maxHTTPAnnounceAttempts, attempts)
+		}
+	})
+
+	t.Run("succeeds after a transient 5xx", func(t *testing.T) {
+		var attempts int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer srv.Close()
+
+		s := NewHTTPAnnounceSender(nil, "")
+		if err := s.postWithRetry(context.Background(), parseURL(t, srv.URL), []byte("{}")); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		if attempts != 2 {
+			t.Fatalf("expected 2 attempts, got %d", attempts)
+		}
+	})
+}
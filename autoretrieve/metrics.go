@@ -0,0 +1,23 @@
+package autoretrieve
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// announceTotal counts advertisement announcements by indexer and outcome,
+// so operators can see which indexers are missing announcements without
+// one unreachable indexer hiding whether the rest are being kept in sync.
+var announceTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "estuary_autoretrieve_announce_total",
+	Help: "Count of autoretrieve advertisement announcements, by indexer and result.",
+}, []string{"indexer", "result"})
+
+// indexerConnected reports, per configured gossipsub indexer, whether it's
+// currently connected (1) or not (0). It's distinct from announceTotal
+// because being connected doesn't mean an advertisement was actually sent;
+// this is just a reachability probe checked once per tick.
+var indexerConnected = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "estuary_autoretrieve_indexer_connected",
+	Help: "Whether a configured gossipsub indexer is currently connected (1) or not (0).",
+}, []string{"indexer"})
@@ -0,0 +1,93 @@
+package autoretrieve
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/filecoin-project/index-provider/metadata"
+	"github.com/ipfs/go-cid"
+	"gorm.io/gorm"
+)
+
+// Retrieval transports an autoretrieve can advertise in Autoretrieve.Protocols.
+const (
+	ProtocolBitswap   = "bitswap"
+	ProtocolGraphsync = "graphsync"
+	ProtocolHTTP      = "http"
+)
+
+// ValidateProtocols checks that every entry in protocols is a known
+// transport and that any parameters it requires (piece CID for graphsync,
+// base URL for HTTP) are present, returning the normalized protocol list.
+// An empty protocols list is valid and defaults to bitswap-only.
+func ValidateProtocols(protocols []string, pieceCid string, httpBaseURL string) ([]string, error) {
+	if len(protocols) == 0 {
+		return []string{ProtocolBitswap}, nil
+	}
+
+	normalized := make([]string, 0, len(protocols))
+	for _, p := range protocols {
+		p = strings.ToLower(strings.TrimSpace(p))
+		switch p {
+		case ProtocolBitswap:
+		case ProtocolGraphsync:
+			if pieceCid == "" {
+				return nil, fmt.Errorf("graphsync protocol requires a piece cid")
+			}
+			if _, err := cid.Decode(pieceCid); err != nil {
+				return nil, fmt.Errorf("invalid piece cid: %w", err)
+			}
+		case ProtocolHTTP:
+			if httpBaseURL == "" {
+				return nil, fmt.Errorf("http protocol requires a base url")
+			}
+		default:
+			return nil, fmt.Errorf("unknown protocol %q", p)
+		}
+		normalized = append(normalized, p)
+	}
+
+	return normalized, nil
+}
+
+// parseProtocols splits an Autoretrieve's stored Protocols column, defaulting
+// to bitswap-only for rows predating the column.
+func parseProtocols(protocols string) []string {
+	if protocols == "" {
+		return []string{ProtocolBitswap}
+	}
+	return strings.Split(protocols, ",")
+}
+
+// metadataForAutoretrieve builds one metadata entry per protocol ar has
+// enabled, so indexers learn every way a CID can be fetched from it.
+func metadataForAutoretrieve(ar Autoretrieve) (metadata.Metadata, error) {
+	var entries []metadata.Protocol
+	for _, p := range parseProtocols(ar.Protocols) {
+		switch p {
+		case ProtocolBitswap:
+			entries = append(entries, &metadata.Bitswap{})
+		case ProtocolGraphsync:
+			pieceCid, err := cid.Decode(ar.PieceCid)
+			if err != nil {
+				return metadata.Metadata{}, fmt.Errorf("invalid piece cid for %s: %w", ar.Handle, err)
+			}
+			entries = append(entries, &metadata.GraphsyncFilecoinV1{
+				PieceCID:      pieceCid,
+				FastRetrieval: true,
+			})
+		case ProtocolHTTP:
+			entries = append(entries, &metadata.IpfsGatewayHttp{})
+		default:
+			return metadata.Metadata{}, fmt.Errorf("autoretrieve %s has unknown protocol %q", ar.Handle, p)
+		}
+	}
+
+	return metadata.New(entries...), nil
+}
+
+// migrateProtocolsDefault backfills the Protocols column for rows created
+// before it existed, defaulting them to bitswap-only.
+func migrateProtocolsDefault(db *gorm.DB) error {
+	return db.Model(&Autoretrieve{}).Where("protocols = ?", "").UpdateColumn("protocols", ProtocolBitswap).Error
+}
@@ -0,0 +1,129 @@
+package autoretrieve
+
+import "testing"
+
+// validTestPieceCid is an arbitrary but valid CIDv1, used only to exercise
+// the piece-cid-decodes-successfully branch of ValidateProtocols.
+const validTestPieceCid = "bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi"
+
+func TestValidateProtocols(t *testing.T) {
+	tests := []struct {
+		name        string
+		protocols   []string
+		pieceCid    string
+		httpBaseURL string
+		want        []string
+		wantErr     bool
+	}{
+		{
+			name:      "empty defaults to bitswap",
+			protocols: nil,
+			want:      []string{ProtocolBitswap},
+		},
+		{
+			name:      "bitswap only",
+			protocols: []string{"bitswap"},
+			want:      []string{ProtocolBitswap},
+		},
+		{
+			name:      "unknown protocol is rejected",
+			protocols: []string{"carrier-pigeon"},
+			wantErr:   true,
+		},
+		{
+			name:      "graphsync without piece cid is rejected",
+			protocols: []string{"graphsync"},
+			wantErr:   true,
+		},
+		{
+			name:      "graphsync with invalid piece cid is rejected",
+			protocols: []string{"graphsync"},
+			pieceCid:  "not-a-cid",
+			wantErr:   true,
+		},
+		{
+			name:      "graphsync with valid piece cid is accepted",
+			protocols: []string{"graphsync"},
+			pieceCid:  validTestPieceCid,
+			want:      []string{ProtocolGraphsync},
+		},
+		{
+			name:      "http without base url is rejected",
+			protocols: []string{"http"},
+			wantErr:   true,
+		},
+		{
+			name:        "http with base url is accepted",
+			protocols:   []string{"http"},
+			httpBaseURL: "https://example.com",
+			want:        []string{ProtocolHTTP},
+		},
+		{
+			name:        "multiple protocols are normalized together",
+			protocols:   []string{" Bitswap ", "HTTP"},
+			httpBaseURL: "https://example.com",
+			want:        []string{ProtocolBitswap, ProtocolHTTP},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ValidateProtocols(tc.protocols, tc.pieceCid, tc.httpBaseURL)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Fatalf("expected %v, got %v", tc.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestParseProtocols(t *testing.T) {
+	tests := []struct {
+		name      string
+		protocols string
+		want      []string
+	}{
+		{
+			name:      "empty defaults to bitswap",
+			protocols: "",
+			want:      []string{ProtocolBitswap},
+		},
+		{
+			name:      "single protocol",
+			protocols: "http",
+			want:      []string{ProtocolHTTP},
+		},
+		{
+			name:      "multiple protocols",
+			protocols: "bitswap,graphsync",
+			want:      []string{ProtocolBitswap, ProtocolGraphsync},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := parseProtocols(tc.protocols)
+			if len(got) != len(tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Fatalf("expected %v, got %v", tc.want, got)
+				}
+			}
+		})
+	}
+}